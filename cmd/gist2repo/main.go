@@ -2,37 +2,79 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-git/go-git/v5"
+	gogitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gogithttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/google/go-github/v28/github"
 	"golang.org/x/oauth2"
+
+	"github.com/atrubachev/gists2repo/config"
+	"github.com/atrubachev/gists2repo/logger"
+	"github.com/atrubachev/gists2repo/source"
 )
 
 const (
 	SYNC2REPO_TOKEN_NAME = "SYNC2REPO_TOKEN"
+	stateFileName        = ".gists2repo/state.json"
 )
 
 var (
-	token    string
-	repoPath string
-	userName string
+	token      string
+	repoPath   string
+	userName   string
+	pollEvery  time.Duration
+	httpAddr   string
+	protocol   string
+	logFormat  string
+	configPath string
+	layout     string
 )
 
 func parseFlags() {
 	flag.StringVar(&token, "token", "", "OAuth token https://github.com/settings/tokens")
 	flag.StringVar(&repoPath, "repo", "", "path to a destination repository on FS")
 	flag.StringVar(&userName, "user", "", "name of user of source gists")
+	flag.DurationVar(&pollEvery, "poll", 0, "if non-zero, keep running and re-sync gists on this interval (e.g. 5m)")
+	flag.StringVar(&httpAddr, "http", "", "if set, serve /healthz, /debug/state and /sync on this address (requires -poll)")
+	flag.StringVar(&protocol, "protocol", "https", "protocol used to reach gists: ssh|https|git")
+	flag.StringVar(&logFormat, "log-format", "json", "log output format: json|console")
+	flag.StringVar(&configPath, "config", "", "path to a YAML config with multiple gist/repo sources and destinations; overrides -user/-repo, and is incompatible with -poll/-http (run it from an external scheduler instead)")
+	flag.StringVar(&layout, "layout", "flat", "how gists land in -repo: flat (today's merge), subtree (gists/<id>/ per gist), or branch (gist/<id> per gist)")
 	flag.Parse()
 }
 
+// stageError wraps an error with the pipeline stage and gist it came from,
+// so the final consumer in main can log a single structured line per
+// failure instead of a bare error string.
+type stageError struct {
+	Stage  string
+	GistID string
+	Err    error
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *stageError) Unwrap() error {
+	return e.Err
+}
+
 func parseEnvs() {
 	t := os.Getenv(SYNC2REPO_TOKEN_NAME)
 	if t != "" {
@@ -52,6 +94,7 @@ func getClient(ctx context.Context, token string) *github.Client {
 func listGists(ctx context.Context, client *github.Client, user string) (<-chan *github.Gist, <-chan error) {
 	gistsCh := make(chan *github.Gist)
 	errCh := make(chan error, 1)
+	sub := logger.CreateSubLogger("stage", "list")
 
 	go func() {
 		opt := &github.GistListOptions{}
@@ -60,13 +103,14 @@ func listGists(ctx context.Context, client *github.Client, user string) (<-chan
 			opt.Page = nextPage
 			gists, resp, err := client.Gists.List(ctx, user, opt)
 			if err != nil {
-				errCh <- err
+				errCh <- &stageError{Stage: "list", Err: err}
 				break
 			}
 			lastPage = resp.LastPage
 			nextPage = resp.NextPage
 
 			for _, gist := range gists {
+				sub.Debug().Str("gist_id", gist.GetID()).Str("gist_url", gist.GetGitPullURL()).Msg("found gist")
 				gistsCh <- gist
 			}
 		}
@@ -77,26 +121,162 @@ func listGists(ctx context.Context, client *github.Client, user string) (<-chan
 	return gistsCh, errCh
 }
 
-func cloneRepos(baseDir string, repos <-chan string) (<-chan string, <-chan error) {
-	pathCh := make(chan string)
+// state tracks, for every gist we have already merged, the UpdatedAt
+// timestamp it had the last time it was synced. It is persisted as JSON
+// inside the destination repo so that a daemon restart does not re-merge
+// everything from scratch.
+type state struct {
+	mu   sync.Mutex
+	path string
+
+	Gists map[string]time.Time `json:"gists"`
+}
+
+func loadState(repoPath string) (*state, error) {
+	s := &state{
+		path:  filepath.Join(repoPath, stateFileName),
+		Gists: map[string]time.Time{},
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *state) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.path, data, 0o644)
+}
+
+// changed reports whether gist has been updated since the last sync.
+func (s *state) changed(gist *github.Gist) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.Gists[gist.GetID()]
+	return !ok || !last.Equal(gist.GetUpdatedAt())
+}
+
+func (s *state) record(gist *github.Gist) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Gists[gist.GetID()] = gist.GetUpdatedAt()
+}
+
+func (s *state) snapshot() map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]time.Time, len(s.Gists))
+	for k, v := range s.Gists {
+		out[k] = v
+	}
+	return out
+}
+
+// filterChanged drops gists whose UpdatedAt matches what is already
+// recorded in state, so that a sync cycle only touches gists that moved.
+func filterChanged(gists <-chan *github.Gist, s *state) <-chan *github.Gist {
+	out := make(chan *github.Gist)
+
+	go func() {
+		for gist := range gists {
+			if s.changed(gist) {
+				out <- gist
+			}
+		}
+		close(out)
+	}()
+
+	return out
+}
+
+// authMethod resolves the go-git transport.AuthMethod to use for the
+// configured -protocol: ssh pulls from the local ssh-agent, https/git
+// authenticate as the OAuth token via HTTP basic auth.
+func authMethod(protocol, token string) (transport.AuthMethod, error) {
+	if protocol == "ssh" {
+		return ssh.NewSSHAgentAuth("git")
+	}
+	return &gogithttp.BasicAuth{Username: "x-access-token", Password: token}, nil
+}
+
+// cloneTarget is something cloneRepos should pull down. Gist is set when
+// the target came from a gist source, so that later pipeline stages (the
+// subtree/branch layouts) can key off the gist's identity instead of just
+// its clone URL.
+type cloneTarget struct {
+	URL  string
+	Gist *github.Gist
+}
+
+// clonedResult is what cloneRepos hands downstream once a target has been
+// cloned to a local path.
+type clonedResult struct {
+	Path string
+	Gist *github.Gist
+}
+
+func cloneRepos(ctx context.Context, baseDir string, targets <-chan cloneTarget, auth transport.AuthMethod) (<-chan clonedResult, <-chan error) {
+	pathCh := make(chan clonedResult)
 	errCh := make(chan error, 1)
 
 	go func() {
 		limit := make(chan struct{}, 30)
 		wg := sync.WaitGroup{}
 
-		for repo := range repos {
+		for target := range targets {
 			wg.Add(1)
-			go func(r string) {
+			go func(t cloneTarget) {
 				limit <- struct{}{}
-				path := filepath.Join(baseDir, repoPathToName(r))
-				if err := execGit("clone", r, path); err != nil {
-					errCh <- err
+				defer func() { <-limit; wg.Done() }()
+
+				sub := logger.CreateSubLogger("stage", "clone", "gist_url", t.URL)
+				path := filepath.Join(baseDir, repoPathToName(t.URL))
+
+				// In -poll mode baseDir is kept across ticks, so a gist that
+				// changed again would otherwise still have its previous
+				// clone on disk here; PlainCloneContext refuses to clone
+				// into a non-empty directory.
+				if err := os.RemoveAll(path); err != nil {
+					sub.Error().Err(err).Msg("remove previous clone")
+					errCh <- &stageError{Stage: "clone", GistID: repoPathToName(t.URL), Err: err}
+					return
 				}
-				pathCh <- path
-				<-limit
-				wg.Done()
-			}(repo)
+
+				_, err := git.PlainCloneContext(ctx, path, false, &git.CloneOptions{
+					URL:  t.URL,
+					Auth: auth,
+				})
+				if err != nil {
+					sub.Error().Err(err).Msg("clone gist")
+					errCh <- &stageError{Stage: "clone", GistID: repoPathToName(t.URL), Err: err}
+					return
+				}
+				pathCh <- clonedResult{Path: path, Gist: t.Gist}
+			}(target)
 		}
 
 		wg.Wait()
@@ -108,25 +288,59 @@ func cloneRepos(baseDir string, repos <-chan string) (<-chan string, <-chan erro
 	return pathCh, errCh
 }
 
-func reposUrl(gists <-chan *github.Gist) <-chan string {
-	urlCh := make(chan string)
+// gistURL resolves the clone URL for gist according to -protocol, mirroring
+// the ssh/https/git choice offered by tools like ghm.
+func gistURL(gist *github.Gist, protocol string) string {
+	switch protocol {
+	case "ssh":
+		return fmt.Sprintf("git@gist.github.com:%s.git", gist.GetID())
+	case "git":
+		return gist.GetGitPushURL()
+	default:
+		return gist.GetGitPullURL()
+	}
+}
+
+// gistTargets turns a stream of gists into clone targets, resolving each
+// one's URL according to -protocol.
+func gistTargets(gists <-chan *github.Gist, protocol string) <-chan cloneTarget {
+	out := make(chan cloneTarget)
 
 	go func() {
 		for gist := range gists {
-			urlCh <- gist.GetGitPullURL()
+			out <- cloneTarget{URL: gistURL(gist, protocol), Gist: gist}
 		}
-		close(urlCh)
+		close(out)
 	}()
 
-	return urlCh
+	return out
+}
+
+// urlTargets wraps a plain stream of clone URLs (e.g. from a config.Repos
+// source, which has no gist identity) as clone targets.
+func urlTargets(urls <-chan string) <-chan cloneTarget {
+	out := make(chan cloneTarget)
+
+	go func() {
+		for url := range urls {
+			out <- cloneTarget{URL: url}
+		}
+		close(out)
+	}()
+
+	return out
 }
 
 func repoPathToName(repo string) string {
 	return strings.TrimRight(filepath.Base(repo), ".git")
 }
 
-func execGit(args ...string) error {
-	cmd := exec.Command("git", args...)
+// execGit runs git against the repo at dir via `-C`, rather than relying on
+// the process's current directory -- mergeOneRepo runs concurrently for
+// every "path:" destination in a -config sync, and os.Chdir is process-wide
+// state that concurrent callers would stomp on.
+func execGit(dir string, args ...string) error {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("%s: %v", output, err)
@@ -134,44 +348,52 @@ func execGit(args ...string) error {
 	return nil
 }
 
-func mergeRepos(dstRepoPath string, repos <-chan string) <-chan error {
+// requireGit fails fast, before any clone work starts, when a "path:"
+// destination is about to need the git binary that mergeRepos/subtreeMerge/
+// branchMerge shell out to; a mirror-only sync never calls this.
+func requireGit() error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("git binary not found on PATH (required to merge gists into a repo): %w", err)
+	}
+	return nil
+}
+
+// mergeRepos merges each cloned gist working copy into dstRepoPath
+// according to layout: "flat" merges everything at the repo root (today's
+// behavior), "subtree" keeps each gist under its own gists/<id-or-slug>/
+// prefix, and "branch" keeps each gist on its own long-lived gist/<id>
+// branch. The remote add/fetch steps go through go-git, but go-git v5 has
+// no general three-way merge implementation, so the actual merge/subtree
+// read-tree/branch fast-forward still shells out to a `git` binary on
+// PATH -- this is a hard dependency, not an optional one.
+func mergeRepos(dstRepoPath, layout string, results <-chan clonedResult) <-chan error {
 	errCh := make(chan error, 1)
 
-	currDir, err := filepath.Abs(".")
+	dst, err := git.PlainOpen(dstRepoPath)
 	if err != nil {
-		errCh <- err
-		close(errCh)
-	}
-	if err := os.Chdir(dstRepoPath); err != nil {
-		errCh <- err
-		close(errCh)
+		// Drain results before returning: its producers (cloneRepos'
+		// workers) send on it unbuffered, so leaving it unread here
+		// would leak every one of those goroutines. Report one
+		// stageError per dropped gist rather than a single bare
+		// error, so callers that skip state.record on a per-gist
+		// basis (syncOnce) don't wrongly mark every gist as synced.
+		go func() {
+			for result := range results {
+				errCh <- &stageError{Stage: "merge", GistID: repoPathToName(result.Path), Err: err}
+			}
+			close(errCh)
+		}()
+		return errCh
 	}
 
 	go func() {
-		for repo := range repos {
-			remoteName := repoPathToName(repo)
-
-			stages := [][]string{
-				// add remote
-				{"remote", "add", remoteName, repo},
-				// fetch remote
-				{"fetch", remoteName},
-				// merge remote
-				{"merge", "--allow-unrelated-histories", "-m", "move gists to repo", remoteName + "/master"},
-				// remove remote
-				{"remote", "rm", remoteName},
+		for result := range results {
+			remoteName := repoPathToName(result.Path)
+			sub := logger.CreateSubLogger("stage", "merge", "remote_name", remoteName, "layout", layout)
+			if err := mergeOneRepo(dst, dstRepoPath, layout, result); err != nil {
+				sub.Error().Err(err).Msg("merge gist")
+				errCh <- &stageError{Stage: "merge", GistID: remoteName, Err: err}
 			}
-
-			for _, args := range stages {
-				if err := execGit(args...); err != nil {
-					errCh <- fmt.Errorf("%s: %v", repo, err)
-					continue
-				}
-			}
-		}
-
-		if err := os.Chdir(currDir); err != nil {
-			errCh <- err
 		}
 		close(errCh)
 	}()
@@ -179,6 +401,71 @@ func mergeRepos(dstRepoPath string, repos <-chan string) <-chan error {
 	return errCh
 }
 
+func mergeOneRepo(dst *git.Repository, dstRepoPath, layout string, result clonedResult) error {
+	remoteName := repoPathToName(result.Path)
+
+	remote, err := dst.CreateRemote(&gogitconfig.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{result.Path},
+	})
+	if err != nil {
+		return err
+	}
+	defer dst.DeleteRemote(remoteName)
+
+	if err := remote.Fetch(&git.FetchOptions{RemoteName: remoteName}); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	switch layout {
+	case "subtree":
+		return subtreeMerge(dstRepoPath, remoteName, result.Gist)
+	case "branch":
+		return branchMerge(dstRepoPath, remoteName, result.Gist)
+	default:
+		return execGit(dstRepoPath, "merge", "--allow-unrelated-histories", "-m", "move gists to repo", remoteName+"/master")
+	}
+}
+
+// subtreeMerge reads the fetched remote's tree into gists/<slug>/ and
+// commits it, so each gist keeps its own collision-free path and `git log
+// -- gists/<id>` stays meaningful.
+func subtreeMerge(dstRepoPath, remoteName string, gist *github.Gist) error {
+	prefix := "gists/" + gistSlug(remoteName, gist) + "/"
+	if err := execGit(dstRepoPath, "read-tree", "--prefix="+prefix, "-u", remoteName+"/master"); err != nil {
+		return err
+	}
+	return execGit(dstRepoPath, "commit", "-m", subtreeCommitMessage(gist))
+}
+
+func subtreeCommitMessage(gist *github.Gist) string {
+	if gist == nil {
+		return "move gist to subtree"
+	}
+	return fmt.Sprintf("gist %s: %s (updated %s)", gist.GetID(), gist.GetDescription(), gist.GetUpdatedAt().Format(time.RFC3339))
+}
+
+// branchMerge keeps every gist on its own long-lived gist/<id> branch,
+// fast-forwarding it on subsequent syncs instead of merging into the
+// checked-out branch.
+func branchMerge(dstRepoPath, remoteName string, gist *github.Gist) error {
+	branch := "gist/" + gistSlug(remoteName, gist)
+
+	if err := execGit(dstRepoPath, "show-ref", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		return execGit(dstRepoPath, "branch", branch, remoteName+"/master")
+	}
+	return execGit(dstRepoPath, "fetch", ".", remoteName+"/master:"+branch)
+}
+
+// gistSlug returns the gist ID when gist is known, falling back to the
+// remote name (derived from the clone URL) for plain repo sources.
+func gistSlug(remoteName string, gist *github.Gist) string {
+	if gist == nil {
+		return remoteName
+	}
+	return gist.GetID()
+}
+
 func mergeErrorChs(channels ...<-chan error) chan error {
 	out := make(chan error)
 	wg := sync.WaitGroup{}
@@ -201,12 +488,284 @@ func mergeErrorChs(channels ...<-chan error) chan error {
 	return out
 }
 
+// syncOnce runs a single list/clone/merge pass against the destination
+// repo, skipping any gist whose UpdatedAt has not moved since the last
+// recorded sync, and updates/persists state for everything it touches.
+func syncOnce(ctx context.Context, client *github.Client, reposDir string, s *state) error {
+	gistsCh, gistsErrCh := listGists(ctx, client, userName)
+
+	var changed []*github.Gist
+	changedCh := make(chan *github.Gist, 1)
+	go func() {
+		for gist := range filterChanged(gistsCh, s) {
+			changed = append(changed, gist)
+			changedCh <- gist
+		}
+		close(changedCh)
+	}()
+
+	if err := requireGit(); err != nil {
+		for range changedCh {
+		}
+		return err
+	}
+
+	auth, err := authMethod(protocol, token)
+	if err != nil {
+		for range changedCh {
+		}
+		return err
+	}
+
+	pathCh, reposErrCh := cloneRepos(ctx, reposDir, gistTargets(changedCh, protocol), auth)
+	mergeErrCh := mergeRepos(repoPath, layout, pathCh)
+
+	sub := logger.CreateSubLogger("stage", "sync")
+	failed := map[string]bool{}
+	var lastErr error
+	for err := range mergeErrorChs(gistsErrCh, reposErrCh, mergeErrCh) {
+		var se *stageError
+		if errors.As(err, &se) {
+			sub.Error().Str("stage", se.Stage).Str("gist_id", se.GistID).Err(se.Err).Msg("sync failed")
+			failed[se.GistID] = true
+		} else {
+			sub.Error().Err(err).Msg("sync failed")
+		}
+		lastErr = err
+	}
+
+	for _, gist := range changed {
+		if failed[repoPathToName(gistURL(gist, protocol))] {
+			continue
+		}
+		s.record(gist)
+	}
+
+	if err := s.save(); err != nil {
+		return err
+	}
+
+	return lastErr
+}
+
+func mergeTargetChs(channels ...<-chan cloneTarget) chan cloneTarget {
+	out := make(chan cloneTarget)
+	wg := sync.WaitGroup{}
+
+	for _, ch := range channels {
+		wg.Add(1)
+		go func(ch <-chan cloneTarget) {
+			for v := range ch {
+				out <- v
+			}
+			wg.Done()
+		}(ch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// mirrorRepos relocates each already-cloned gist/repo working copy into
+// its own directory under mirrorDir, giving a bare-clone-per-repo layout
+// instead of merging everything into one tree.
+func mirrorRepos(mirrorDir string, results <-chan clonedResult) <-chan error {
+	errCh := make(chan error, 1)
+
+	go func() {
+		for result := range results {
+			dst := filepath.Join(mirrorDir, filepath.Base(result.Path))
+			if err := os.MkdirAll(mirrorDir, 0o755); err != nil {
+				errCh <- err
+				continue
+			}
+			if err := os.RemoveAll(dst); err != nil {
+				errCh <- err
+				continue
+			}
+			if err := os.Rename(result.Path, dst); err != nil {
+				errCh <- err
+			}
+		}
+		close(errCh)
+	}()
+
+	return errCh
+}
+
+// runConfig drives a -config sync: every declared gist/repo source is
+// listed (through its own filters and, if set, its own token_env) into one
+// merged clone-target stream -- gist sources keep their *github.Gist so the
+// subtree/branch layouts can still key off it -- which is then fanned out,
+// one clone+merge/mirror pass per declared destination.
+func runConfig(ctx context.Context, cfgPath string) error {
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	var targetChs []<-chan cloneTarget
+	var errChs []<-chan error
+
+	for _, gs := range cfg.Gists {
+		t := source.TokenFromEnv(gs.TokenEnv)
+		if t == "" {
+			t = token
+		}
+		gistCh, errCh := source.NewGists(gs).List(ctx, getClient(ctx, t))
+		targetChs = append(targetChs, gistTargets(gistCh, protocol))
+		errChs = append(errChs, errCh)
+	}
+
+	for _, rs := range cfg.Repos {
+		t := source.TokenFromEnv(rs.TokenEnv)
+		if t == "" {
+			t = token
+		}
+		urlCh, errCh := source.NewRepos(rs).List(ctx, getClient(ctx, t))
+		targetChs = append(targetChs, urlTargets(urlCh))
+		errChs = append(errChs, errCh)
+	}
+
+	dests := make([]chan cloneTarget, len(cfg.Dest))
+	for i := range dests {
+		dests[i] = make(chan cloneTarget)
+	}
+	go func() {
+		for t := range mergeTargetChs(targetChs...) {
+			for _, d := range dests {
+				d <- t
+			}
+		}
+		for _, d := range dests {
+			close(d)
+		}
+	}()
+
+	auth, err := authMethod(protocol, token)
+	if err != nil {
+		return err
+	}
+
+	sub := logger.CreateSubLogger("stage", "config")
+
+	var mu sync.Mutex
+	var lastErr error
+	setErr := func(err error) {
+		mu.Lock()
+		lastErr = err
+		mu.Unlock()
+	}
+
+	destWg := sync.WaitGroup{}
+	for i, dest := range cfg.Dest {
+		destWg.Add(1)
+		go func(dest config.Destination, targets <-chan cloneTarget) {
+			defer destWg.Done()
+
+			if dest.Path != "" {
+				if err := requireGit(); err != nil {
+					sub.Error().Err(err).Msg("sync failed")
+					setErr(err)
+					for range targets {
+					}
+					return
+				}
+			}
+
+			reposDir, err := ioutil.TempDir("", "")
+			if err != nil {
+				sub.Error().Err(err).Msg("create temp dir")
+				setErr(err)
+				for range targets {
+				}
+				return
+			}
+			defer os.RemoveAll(reposDir)
+
+			pathCh, cloneErrCh := cloneRepos(ctx, reposDir, targets, auth)
+
+			// config.Load rejects a dest with neither field set, so
+			// exactly one of these always applies.
+			var destErrCh <-chan error
+			if dest.Path != "" {
+				destErrCh = mergeRepos(dest.Path, layout, pathCh)
+			} else {
+				destErrCh = mirrorRepos(dest.Mirror, pathCh)
+			}
+
+			for err := range mergeErrorChs(cloneErrCh, destErrCh) {
+				sub.Error().Err(err).Msg("sync failed")
+				setErr(err)
+			}
+		}(dest, dests[i])
+	}
+
+	for err := range mergeErrorChs(errChs...) {
+		sub.Error().Err(err).Msg("list failed")
+		setErr(err)
+	}
+	destWg.Wait()
+
+	return lastErr
+}
+
+// serveHTTP exposes /healthz, /debug/state and /sync so the daemon can be
+// probed and driven out-of-band, e.g. from a systemd unit or container
+// orchestrator. syncNow is called synchronously from the /sync handler.
+func serveHTTP(addr string, s *state, syncNow func() error) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/debug/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.snapshot()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/sync", func(w http.ResponseWriter, r *http.Request) {
+		if err := syncNow(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "synced")
+	})
+
+	sub := logger.CreateSubLogger("stage", "http")
+	sub.Info().Str("addr", addr).Msg("serving http")
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		sub.Fatal().Err(err).Msg("http server")
+	}
+}
+
 func main() {
 	parseEnvs()
 	parseFlags()
+	logger.Init(logFormat)
+	sub := logger.CreateSubLogger()
+
+	if configPath != "" {
+		if pollEvery != 0 || httpAddr != "" {
+			sub.Fatal().Msg("-config does not yet support -poll/-http; run it from an external scheduler (e.g. cron or a systemd timer) instead")
+		}
+		if err := runConfig(context.Background(), configPath); err != nil {
+			sub.Fatal().Err(err).Msg("config sync failed")
+		}
+		return
+	}
 
 	if userName == "" || token == "" || repoPath == "" {
-		log.Fatal("One or more arguments have not been passed")
+		sub.Fatal().Msg("One or more arguments have not been passed")
 	}
 
 	ctx := context.Background()
@@ -214,15 +773,50 @@ func main() {
 
 	reposDir, err := ioutil.TempDir("", "")
 	if err != nil {
-		log.Fatalf("Cannot create temp directory: %v", err)
+		sub.Fatal().Err(err).Msg("Cannot create temp directory")
 	}
+
+	s, err := loadState(repoPath)
+	if err != nil {
+		sub.Fatal().Err(err).Msg("Cannot load state")
+	}
+
+	// syncMu serializes syncOnce calls: the poll ticker, the initial
+	// sync, and any /sync request (each served on its own goroutine by
+	// net/http) would otherwise race on the same reposDir and repoPath,
+	// corrupting the destination repo's working tree.
+	var syncMu sync.Mutex
+	syncNow := func() error {
+		syncMu.Lock()
+		defer syncMu.Unlock()
+		return syncOnce(ctx, client, reposDir, s)
+	}
+
+	if pollEvery == 0 {
+		defer os.RemoveAll(reposDir)
+		if err := syncNow(); err != nil {
+			sub.Error().Err(err).Msg("sync failed")
+		}
+		return
+	}
+
+	// Daemon mode: the temp clone dir is kept around across ticks and
+	// only cleaned up on exit.
 	defer os.RemoveAll(reposDir)
 
-	gistsCh, gistsErrCh := listGists(ctx, client, userName)
-	pathCh, reposErrCh := cloneRepos(reposDir, reposUrl(gistsCh))
-	mergeErrCh := mergeRepos(repoPath, pathCh)
+	if httpAddr != "" {
+		go serveHTTP(httpAddr, s, syncNow)
+	}
 
-	for err := range mergeErrorChs(gistsErrCh, reposErrCh, mergeErrCh) {
-		log.Println(err)
+	if err := syncNow(); err != nil {
+		sub.Error().Err(err).Msg("sync failed")
+	}
+
+	ticker := time.NewTicker(pollEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := syncNow(); err != nil {
+			sub.Error().Err(err).Msg("sync failed")
+		}
 	}
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+)
+
+func TestGistURL(t *testing.T) {
+	gist := &github.Gist{
+		ID:         github.String("abc123"),
+		GitPullURL: github.String("https://gist.github.com/abc123.git"),
+		GitPushURL: github.String("https://gist.github.com/abc123.git"),
+	}
+
+	cases := []struct {
+		protocol string
+		want     string
+	}{
+		{"ssh", "git@gist.github.com:abc123.git"},
+		{"git", "https://gist.github.com/abc123.git"},
+		{"https", "https://gist.github.com/abc123.git"},
+		{"", "https://gist.github.com/abc123.git"},
+	}
+
+	for _, c := range cases {
+		if got := gistURL(gist, c.protocol); got != c.want {
+			t.Errorf("gistURL(gist, %q) = %q, want %q", c.protocol, got, c.want)
+		}
+	}
+}
+
+func TestRepoPathToName(t *testing.T) {
+	cases := map[string]string{
+		"/tmp/foo/bar.git":                   "bar",
+		"/tmp/foo/bar":                       "bar",
+		"https://gist.github.com/abc123.git": "abc123",
+	}
+
+	for in, want := range cases {
+		if got := repoPathToName(in); got != want {
+			t.Errorf("repoPathToName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGistSlug(t *testing.T) {
+	if got := gistSlug("some-remote", nil); got != "some-remote" {
+		t.Errorf("gistSlug with nil gist = %q, want %q", got, "some-remote")
+	}
+
+	gist := &github.Gist{ID: github.String("abc123")}
+	if got := gistSlug("some-remote", gist); got != "abc123" {
+		t.Errorf("gistSlug with gist = %q, want %q", got, "abc123")
+	}
+}
+
+func TestSubtreeCommitMessage(t *testing.T) {
+	if got := subtreeCommitMessage(nil); got != "move gist to subtree" {
+		t.Errorf("subtreeCommitMessage(nil) = %q, want %q", got, "move gist to subtree")
+	}
+
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	gist := &github.Gist{
+		ID:          github.String("abc123"),
+		Description: github.String("a test gist"),
+		UpdatedAt:   &updated,
+	}
+	want := "gist abc123: a test gist (updated 2024-03-01T12:00:00Z)"
+	if got := subtreeCommitMessage(gist); got != want {
+		t.Errorf("subtreeCommitMessage(gist) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Package config defines the YAML schema for gist2repo's -config flag,
+// modeled after gickup's types.Conf: a list of gist/repo sources, each
+// with its own filters, feeding one or more destinations.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Conf is the top-level -config file schema.
+type Conf struct {
+	Gists []GistSource  `yaml:"gists"`
+	Repos []RepoSource  `yaml:"repos"`
+	Dest  []Destination `yaml:"dest"`
+}
+
+// Filter fields shared by every source kind.
+type Filter struct {
+	Include    []string `yaml:"include"`
+	Exclude    []string `yaml:"exclude"`
+	Visibility string   `yaml:"visibility"` // public|private|all
+	Since      string   `yaml:"since"`      // e.g. "30d"
+}
+
+// GistSource pulls every gist owned by User.
+type GistSource struct {
+	User     string `yaml:"user"`
+	TokenEnv string `yaml:"token_env"`
+	Filter   `yaml:",inline"`
+}
+
+// RepoSource pulls repos owned by User, or every repo in Org when Org is
+// set. ExcludeForks lives here rather than on Filter: go-github's Gist
+// type has no fork indicator, so a gists: source has no way to honor it.
+type RepoSource struct {
+	User         string `yaml:"user"`
+	Org          string `yaml:"org"`
+	TokenEnv     string `yaml:"token_env"`
+	ExcludeForks bool   `yaml:"exclude_forks"`
+	Filter       `yaml:",inline"`
+}
+
+// Destination is either a local merge-into-one-repo path (today's
+// behavior) or a mirror root that gets a bare clone per repo.
+type Destination struct {
+	Path   string `yaml:"path"`
+	Mirror string `yaml:"mirror"`
+}
+
+// Load reads and parses a Conf from path.
+func Load(path string) (*Conf, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Conf
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	for i, dest := range c.Dest {
+		if dest.Path == "" && dest.Mirror == "" {
+			return nil, fmt.Errorf("dest[%d]: one of path or mirror must be set", i)
+		}
+		if dest.Path != "" && dest.Mirror != "" {
+			return nil, fmt.Errorf("dest[%d]: path and mirror are mutually exclusive", i)
+		}
+	}
+
+	return &c, nil
+}
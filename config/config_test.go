@@ -0,0 +1,66 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConf(t *testing.T, yaml string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gists2repo.yaml")
+	if err := ioutil.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadValidDest(t *testing.T) {
+	path := writeConf(t, `
+gists:
+  - user: alice
+dest:
+  - path: /repo
+  - mirror: /mirror
+`)
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(c.Dest) != 2 || c.Dest[0].Path != "/repo" || c.Dest[1].Mirror != "/mirror" {
+		t.Errorf("unexpected Dest: %+v", c.Dest)
+	}
+}
+
+func TestLoadRejectsEmptyDest(t *testing.T) {
+	path := writeConf(t, `
+dest:
+  - {}
+`)
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load with a dest lacking path and mirror returned no error")
+	}
+}
+
+func TestLoadRejectsAmbiguousDest(t *testing.T) {
+	path := writeConf(t, `
+dest:
+  - path: /repo
+    mirror: /mirror
+`)
+
+	if _, err := Load(path); err == nil {
+		t.Error("Load with a dest setting both path and mirror returned no error")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if _, err := Load(filepath.Join(os.TempDir(), "does-not-exist.yaml")); err == nil {
+		t.Error("Load of a missing file returned no error")
+	}
+}
@@ -0,0 +1,33 @@
+// Package logger provides the structured, per-stage zerolog loggers used
+// across gist2repo, following the same sublogger-per-item shape as gickup.
+package logger
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+var base = zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+// Init configures the package-level base logger's output format: "json"
+// (the default) for machine-parseable lines, or "console" for a
+// human-friendly colored writer suitable for local runs.
+func Init(format string) {
+	if format == "console" {
+		base = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr}).With().Timestamp().Logger()
+		return
+	}
+	base = zerolog.New(os.Stderr).With().Timestamp().Logger()
+}
+
+// CreateSubLogger returns a copy of the base logger with kv given as
+// alternating key/value strings attached as structured fields, e.g.
+// CreateSubLogger("stage", "clone", "gist_id", id).
+func CreateSubLogger(kv ...string) zerolog.Logger {
+	ctx := base.With()
+	for i := 0; i+1 < len(kv); i += 2 {
+		ctx = ctx.Str(kv[i], kv[i+1])
+	}
+	return ctx.Logger()
+}
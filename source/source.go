@@ -0,0 +1,208 @@
+// Package source generalizes gist2repo's single (user, token) gist list
+// into a Source interface with two implementations -- gists owned by a
+// user, and repos owned by a user or org -- each carrying its own
+// include/exclude/visibility/since filters from the YAML config.
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+
+	"github.com/atrubachev/gists2repo/config"
+)
+
+// Source streams the clone URLs of everything it selects, applying its
+// filters before a URL is ever enqueued downstream.
+type Source interface {
+	List(ctx context.Context, client *github.Client) (<-chan string, <-chan error)
+}
+
+// Gists lists every gist owned by cfg.User. It does not implement Source:
+// unlike a plain URL stream, Gists.List keeps each gist's metadata (ID,
+// description, UpdatedAt) around for the subtree/branch layouts downstream.
+type Gists struct {
+	cfg config.GistSource
+}
+
+// NewGists returns a Gists source over cfg.
+func NewGists(cfg config.GistSource) *Gists {
+	return &Gists{cfg: cfg}
+}
+
+func (g *Gists) List(ctx context.Context, client *github.Client) (<-chan *github.Gist, <-chan error) {
+	gistCh := make(chan *github.Gist)
+	errCh := make(chan error, 1)
+
+	since, err := parseSince(g.cfg.Since)
+	if err != nil {
+		errCh <- err
+		close(errCh)
+		close(gistCh)
+		return gistCh, errCh
+	}
+
+	go func() {
+		opt := &github.GistListOptions{Since: since}
+		var nextPage, lastPage = 0, 1
+		for lastPage != 0 && nextPage <= lastPage {
+			opt.Page = nextPage
+			gists, resp, err := client.Gists.List(ctx, g.cfg.User, opt)
+			if err != nil {
+				errCh <- err
+				break
+			}
+			lastPage = resp.LastPage
+			nextPage = resp.NextPage
+
+			for _, gist := range gists {
+				if !matchFilter(g.cfg.Filter, gist.GetDescription(), gist.GetPublic()) {
+					continue
+				}
+				gistCh <- gist
+			}
+		}
+		close(errCh)
+		close(gistCh)
+	}()
+
+	return gistCh, errCh
+}
+
+// Repos lists repos owned by cfg.User, or by cfg.Org when it is set.
+type Repos struct {
+	cfg config.RepoSource
+}
+
+// NewRepos returns a Source over cfg.
+func NewRepos(cfg config.RepoSource) *Repos {
+	return &Repos{cfg: cfg}
+}
+
+func (r *Repos) List(ctx context.Context, client *github.Client) (<-chan string, <-chan error) {
+	urlCh := make(chan string)
+	errCh := make(chan error, 1)
+
+	since, err := parseSince(r.cfg.Since)
+	if err != nil {
+		errCh <- err
+		close(errCh)
+		close(urlCh)
+		return urlCh, errCh
+	}
+
+	go func() {
+		var nextPage, lastPage = 0, 1
+		for lastPage != 0 && nextPage <= lastPage {
+			var repos []*github.Repository
+			var resp *github.Response
+			var err error
+
+			if r.cfg.Org != "" {
+				opt := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{Page: nextPage}}
+				repos, resp, err = client.Repositories.ListByOrg(ctx, r.cfg.Org, opt)
+			} else {
+				opt := &github.RepositoryListOptions{ListOptions: github.ListOptions{Page: nextPage}}
+				repos, resp, err = client.Repositories.List(ctx, r.cfg.User, opt)
+			}
+			if err != nil {
+				errCh <- err
+				break
+			}
+			lastPage = resp.LastPage
+			nextPage = resp.NextPage
+
+			for _, repo := range repos {
+				if r.cfg.ExcludeForks && repo.GetFork() {
+					continue
+				}
+				if !since.IsZero() && repo.GetUpdatedAt().Before(since) {
+					continue
+				}
+				if !matchFilter(r.cfg.Filter, repo.GetName(), !repo.GetPrivate()) {
+					continue
+				}
+				urlCh <- repo.GetCloneURL()
+			}
+		}
+		close(errCh)
+		close(urlCh)
+	}()
+
+	return urlCh, errCh
+}
+
+// matchFilter applies a Filter's include/exclude globs (matched against
+// name) and visibility setting (matched against isPublic).
+func matchFilter(f config.Filter, name string, isPublic bool) bool {
+	switch f.Visibility {
+	case "public":
+		if !isPublic {
+			return false
+		}
+	case "private":
+		if isPublic {
+			return false
+		}
+	}
+
+	for _, pattern := range f.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSince turns a "30d"/"12h"/"45m" style duration into the cutoff
+// time.Time github's Since filters expect. An empty string means "no
+// cutoff".
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+
+	unit := since[len(since)-1:]
+	amount, err := strconv.Atoi(since[:len(since)-1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q: %v", since, err)
+	}
+
+	var d time.Duration
+	switch unit {
+	case "d":
+		d = time.Duration(amount) * 24 * time.Hour
+	case "h":
+		d = time.Duration(amount) * time.Hour
+	case "m":
+		d = time.Duration(amount) * time.Minute
+	default:
+		return time.Time{}, fmt.Errorf("invalid since %q: unknown unit %q", since, unit)
+	}
+
+	return time.Now().Add(-d), nil
+}
+
+// TokenFromEnv resolves a token_env config field to its environment value,
+// falling back to "" when tokenEnv is empty.
+func TokenFromEnv(tokenEnv string) string {
+	if tokenEnv == "" {
+		return ""
+	}
+	return strings.TrimSpace(os.Getenv(tokenEnv))
+}
@@ -0,0 +1,64 @@
+package source
+
+import (
+	"testing"
+	"time"
+
+	"github.com/atrubachev/gists2repo/config"
+)
+
+func TestParseSince(t *testing.T) {
+	if got, err := parseSince(""); err != nil || !got.IsZero() {
+		t.Errorf("parseSince(\"\") = (%v, %v), want (zero time, nil)", got, err)
+	}
+
+	cases := []struct {
+		since string
+		want  time.Duration
+	}{
+		{"30d", 30 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"45m", 45 * time.Minute},
+	}
+	for _, c := range cases {
+		got, err := parseSince(c.since)
+		if err != nil {
+			t.Fatalf("parseSince(%q) returned error: %v", c.since, err)
+		}
+		wantAfter := time.Now().Add(-c.want - time.Minute)
+		wantBefore := time.Now().Add(-c.want + time.Minute)
+		if got.Before(wantAfter) || got.After(wantBefore) {
+			t.Errorf("parseSince(%q) = %v, want within a minute of %v ago", c.since, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"30x", "xd", "d"} {
+		if _, err := parseSince(bad); err == nil {
+			t.Errorf("parseSince(%q) returned no error, want one", bad)
+		}
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	cases := []struct {
+		name     string
+		f        config.Filter
+		itemName string
+		isPublic bool
+		want     bool
+	}{
+		{"no filter matches everything", config.Filter{}, "anything", true, true},
+		{"visibility public excludes private", config.Filter{Visibility: "public"}, "x", false, false},
+		{"visibility private excludes public", config.Filter{Visibility: "private"}, "x", true, false},
+		{"exclude glob wins", config.Filter{Exclude: []string{"secret-*"}}, "secret-one", true, false},
+		{"include glob required", config.Filter{Include: []string{"keep-*"}}, "other", true, false},
+		{"include glob matches", config.Filter{Include: []string{"keep-*"}}, "keep-this", true, true},
+		{"exclude beats include", config.Filter{Include: []string{"*"}, Exclude: []string{"keep-this"}}, "keep-this", true, false},
+	}
+
+	for _, c := range cases {
+		if got := matchFilter(c.f, c.itemName, c.isPublic); got != c.want {
+			t.Errorf("%s: matchFilter(%+v, %q, %v) = %v, want %v", c.name, c.f, c.itemName, c.isPublic, got, c.want)
+		}
+	}
+}